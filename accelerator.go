@@ -0,0 +1,257 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"github.com/scroot/winc/w32"
+)
+
+// accelTables tracks the one accelerator table a form owns, keyed by the
+// form's hwnd, so MenuItem.update() can mark it dirty without threading a
+// reference through every menu call.
+var accelTables = make(map[w32.HWND]*AccelTable)
+
+// AccelTable owns the ACCEL table synthesized from the shortcuts currently
+// attached to a form's menu, and the system-wide hotkeys registered
+// against that form independent of any menu. Get one via
+// ControlBase.Accelerators().
+type AccelTable struct {
+	hwnd     w32.HWND
+	hAccel   w32.HACCEL
+	dirty    bool
+	hotkeys  map[int]func()
+	nextHKID int
+}
+
+func newAccelTable(hwnd w32.HWND) *AccelTable {
+	at := &AccelTable{hwnd: hwnd, dirty: true, hotkeys: make(map[int]func()), nextHKID: 1}
+	accelTables[hwnd] = at
+	addChainedHandler(hwnd, at.handleHotKeyMsg)
+	addChainedHandler(hwnd, at.handleKeyDownMsg)
+	return at
+}
+
+// ensureAccelTable installs hwnd's accelerator table, once, so menu
+// shortcuts dispatch via WM_KEYDOWN as soon as a menu is shown, the same
+// way ensureMenuDrawHook/ensureMenuSelectHook wire their own hooks. Called
+// by Menu.Show() so an application that never calls
+// ControlBase.Accelerators() itself still gets live keyboard dispatch for
+// the shortcuts its menu already displays.
+func ensureAccelTable(hwnd w32.HWND) {
+	if _, ok := accelTables[hwnd]; ok {
+		return
+	}
+	newAccelTable(hwnd)
+}
+
+// Accelerators returns cba's accelerator table, creating it on first use.
+// Menu shortcuts fire automatically via WM_KEYDOWN (see handleKeyDownMsg);
+// Table() exists for an application that runs its own GetMessage loop and
+// wants to pass hAccel to TranslateAccelerator itself, e.g. to also cover
+// dialog navigation keys winc's own dispatch doesn't handle.
+func (cba *ControlBase) Accelerators() *AccelTable {
+	cba.hookMu.Lock()
+	defer cba.hookMu.Unlock()
+	if cba.accel == nil {
+		if at, ok := accelTables[cba.hwnd]; ok {
+			cba.accel = at
+		} else {
+			cba.accel = newAccelTable(cba.hwnd)
+		}
+	}
+	return cba.accel
+}
+
+// RegisterGlobalHotKey registers a system-wide hotkey via RegisterHotKey
+// that fires fn on WM_HOTKEY even while the form is not focused,
+// independent of whether shortcut also appears in a menu.
+func (cba *ControlBase) RegisterGlobalHotKey(shortcut Shortcut, fn func()) {
+	cba.Accelerators().RegisterGlobalHotKey(shortcut, fn)
+}
+
+// markAllAccelTablesDirty flags every form's accelerator table for rebuild.
+// Shortcuts are keyed globally (actionsByID), not per form, so there is no
+// cheap way to know which form(s) a changed MenuItem's table belongs to;
+// rebuilding is lazy and cheap enough that marking all of them is fine.
+func markAllAccelTablesDirty() {
+	for _, at := range accelTables {
+		at.markDirty()
+	}
+}
+
+// markDirty flags the accelerator table for rebuild the next time it is
+// needed. Called from MenuItem.update() whenever a.shortcut may have
+// changed.
+func (at *AccelTable) markDirty() {
+	at.dirty = true
+}
+
+// handleKeyDownMsg matches WM_KEYDOWN/WM_SYSKEYDOWN against the menu
+// shortcuts currently attached to at.hwnd and fires the matching
+// MenuItem's OnClick, the same effect TranslateAccelerator+WM_COMMAND
+// would have. This is what actually makes a shortcut shown in a menu
+// fire from the keyboard, independent of whether the application runs
+// its own message loop through Table(). Only items whose ownerHwnd is
+// at.hwnd (see assignMenuOwner) are considered, so two forms binding the
+// same shortcut don't cross-fire each other's MenuItem.
+func (at *AccelTable) handleKeyDownMsg(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	if msg != w32.WM_KEYDOWN && msg != w32.WM_SYSKEYDOWN {
+		return 0, false
+	}
+
+	for _, mi := range actionsByID {
+		if mi.ownerHwnd != at.hwnd {
+			continue
+		}
+		s := mi.shortcut
+		if s.Key == 0 || int(s.Key) != int(wparam) {
+			continue
+		}
+		if !shortcutModifiersMatch(s) {
+			continue
+		}
+		if !mi.Enabled() {
+			return 0, false
+		}
+		mi.onClick.Fire()
+		return 0, true
+	}
+	return 0, false
+}
+
+// shortcutModifiersMatch compares the live Shift/Ctrl/Alt key state against
+// s.Mod the same way TranslateAccelerator would.
+func shortcutModifiersMatch(s Shortcut) bool {
+	shiftDown := w32.GetKeyState(w32.VK_SHIFT) < 0
+	ctrlDown := w32.GetKeyState(w32.VK_CONTROL) < 0
+	altDown := w32.GetKeyState(w32.VK_MENU) < 0
+	return shiftDown == (s.Mod&ModShift != 0) &&
+		ctrlDown == (s.Mod&ModControl != 0) &&
+		altDown == (s.Mod&ModAlt != 0)
+}
+
+// Table returns the current ACCEL table, rebuilding it first if any menu
+// item's shortcut changed since the last call.
+func (at *AccelTable) Table() w32.HACCEL {
+	if at.dirty {
+		at.rebuild()
+	}
+	return at.hAccel
+}
+
+func (at *AccelTable) rebuild() {
+	if at.hAccel != 0 {
+		w32.DestroyAcceleratorTable(at.hAccel)
+		at.hAccel = 0
+	}
+
+	var accels []w32.ACCEL
+	for id, mi := range actionsByID {
+		if mi.ownerHwnd != at.hwnd {
+			continue
+		}
+		s := mi.shortcut
+		if s.Key == 0 {
+			continue
+		}
+		fVirt := w32.FVIRTKEY
+		if s.Mod&ModShift != 0 {
+			fVirt |= w32.FSHIFT
+		}
+		if s.Mod&ModControl != 0 {
+			fVirt |= w32.FCONTROL
+		}
+		if s.Mod&ModAlt != 0 {
+			fVirt |= w32.FALT
+		}
+		accels = append(accels, w32.ACCEL{
+			FVirt: byte(fVirt),
+			Key:   uint16(s.Key),
+			Cmd:   id,
+		})
+	}
+
+	if len(accels) > 0 {
+		at.hAccel = w32.CreateAcceleratorTable(accels)
+	}
+	at.dirty = false
+}
+
+// Destroy releases the underlying accelerator table. Called on form close.
+func (at *AccelTable) Destroy() {
+	if at.hAccel != 0 {
+		w32.DestroyAcceleratorTable(at.hAccel)
+		at.hAccel = 0
+	}
+}
+
+// RegisterGlobalHotKey registers a system-wide hotkey via RegisterHotKey
+// that fires fn on WM_HOTKEY even while the form is not focused,
+// independent of whether shortcut also appears in a menu.
+func (at *AccelTable) RegisterGlobalHotKey(shortcut Shortcut, fn func()) {
+	id := at.nextHKID
+	at.nextHKID++
+
+	var fsModifiers uint32
+	if shortcut.Mod&ModShift != 0 {
+		fsModifiers |= w32.MOD_SHIFT
+	}
+	if shortcut.Mod&ModControl != 0 {
+		fsModifiers |= w32.MOD_CONTROL
+	}
+	if shortcut.Mod&ModAlt != 0 {
+		fsModifiers |= w32.MOD_ALT
+	}
+
+	if !w32.RegisterHotKey(at.hwnd, id, fsModifiers, uint32(shortcut.Key)) {
+		panic("RegisterHotKey failed")
+	}
+	at.hotkeys[id] = fn
+}
+
+// handleHotKeyMsg dispatches WM_HOTKEY to the registered callback for its
+// id, if any. Installed on at.hwnd's extension chain (see msgchain.go)
+// by newAccelTable.
+func (at *AccelTable) handleHotKeyMsg(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	if msg != w32.WM_HOTKEY {
+		return 0, false
+	}
+	at.dispatchHotKey(int(wparam))
+	return 0, true
+}
+
+// dispatchHotKey runs the callback registered for a WM_HOTKEY id, if any.
+func (at *AccelTable) dispatchHotKey(id int) {
+	if fn, ok := at.hotkeys[id]; ok {
+		fn()
+	}
+}
+
+// unregisterHotKeys unregisters every hotkey this table owns. Called on
+// form close alongside Destroy.
+func (at *AccelTable) unregisterHotKeys() {
+	for id := range at.hotkeys {
+		w32.UnregisterHotKey(at.hwnd, id)
+	}
+	at.hotkeys = make(map[int]func())
+}
+
+// closeAccelerators destroys cba.hwnd's accelerator table, unregisters
+// its global hotkeys, and forgets it in accelTables, if it has one.
+// Looked up by hwnd rather than cba.accel, since Menu.Show() may have
+// installed one via ensureAccelTable without cba.accel ever being set.
+// Called by ControlBase.Close(); without the accelTables cleanup, a
+// later window that reuses the same (OS-recycled) hwnd would find a
+// stale *AccelTable already registered and markAllAccelTablesDirty would
+// keep poking a table whose hAccel/hotkeys belong to a destroyed window.
+func (cba *ControlBase) closeAccelerators() {
+	at, ok := accelTables[cba.hwnd]
+	if !ok {
+		return
+	}
+	at.Destroy()
+	at.unregisterHotKeys()
+	delete(accelTables, cba.hwnd)
+}
@@ -7,6 +7,7 @@ package winc
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
 
 	"github.com/scroot/winc/w32"
@@ -17,8 +18,19 @@ type ControlBase struct {
 	font        *Font
 	parent      Controller
 	contextMenu *MenuItem
+	tooltip     *Tooltip
+	accel       *AccelTable
 
-	isForm bool
+	isForm       bool
+	dpiAware     bool
+	dpiHooked    bool
+	invokeHooked bool
+	lastDPI      int
+
+	// hookMu guards the lazy one-time installs above (accel,
+	// invokeHooked) against concurrent callers, since Invoke/BeginInvoke
+	// are explicitly meant to be called from other goroutines.
+	hookMu sync.Mutex
 
 	// General events
 	onCreate EventManager
@@ -52,6 +64,9 @@ type ControlBase struct {
 	// Paint events
 	onPaint EventManager
 	onSize  EventManager
+
+	// DPI events
+	onDPIChanged EventManager
 }
 
 // initControl is called by controls: edit, button, treeview, listview, and so on.
@@ -116,11 +131,18 @@ func (cba *ControlBase) Text() string {
 }
 
 func (cba *ControlBase) Close() {
+	cancelPendingInvokes(cba.hwnd)
+	removeChainedHandlers(cba.hwnd)
+	cba.closeAccelerators()
+	cba.closeTooltip()
+	cba.closeMenuDrawHook()
+	cba.closeMenuSelectHook()
 	UnRegMsgHandler(cba.hwnd)
 	w32.DestroyWindow(cba.hwnd)
 }
 
 func (cba *ControlBase) SetSize(width, height int) {
+	width, height = cba.dpiAwareSize(width, height)
 	x, y := cba.Pos()
 	w32.MoveWindow(cba.hwnd, x, y, width, height, true)
 }
@@ -143,6 +165,7 @@ func (cba *ControlBase) Height() int {
 }
 
 func (cba *ControlBase) SetPos(x, y int) {
+	x, y = cba.dpiAwarePos(x, y)
 	w, h := cba.Size()
 	if w == 0 {
 		w = 100
@@ -183,6 +206,10 @@ func (cba *ControlBase) ContextMenu() *MenuItem {
 
 func (cba *ControlBase) SetContextMenu(menu *MenuItem) {
 	cba.contextMenu = menu
+	ensureMenuDrawHook(cba.hwnd)
+	ensureMenuSelectHook(cba.hwnd)
+	menu.ownerHwnd = cba.hwnd
+	assignMenuOwner(menu.hSubMenu, cba.hwnd)
 }
 
 func (cba *ControlBase) Bounds() *Rect {
@@ -252,6 +279,9 @@ func (cba *ControlBase) Font() *Font {
 }
 
 func (cba *ControlBase) SetFont(font *Font) {
+	if cba.dpiAware {
+		font = font.withDPI(cba.DPI())
+	}
 	w32.SendMessage(cba.hwnd, w32.WM_SETFONT, uintptr(font.hfont), 1)
 	cba.font = font
 }
@@ -266,7 +296,7 @@ func (cba *ControlBase) InvokeRequired() bool {
 	}
 
 	windowThreadId, _ := w32.GetWindowThreadProcessId(cba.hwnd)
-	currentThreadId := w32.GetCurrentThread()
+	currentThreadId := w32.GetCurrentThreadId()
 
 	return windowThreadId != currentThreadId
 }
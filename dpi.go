@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"unsafe"
+
+	"github.com/scroot/winc/w32"
+)
+
+// baseDPI is the reference DPI Windows uses for 100% scaling.
+const baseDPI = 96
+
+// EnableDPIAwareness opts the whole process into per-monitor v2 DPI
+// awareness. It must be called once, before any window is created
+// (typically as the first statement in main), and is a no-op on systems
+// that predate SetProcessDpiAwarenessContext.
+func EnableDPIAwareness() {
+	w32.SetProcessDpiAwarenessContext(w32.DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2)
+}
+
+// DPI returns the DPI of the monitor the control currently lives on, or
+// baseDPI if it cannot be determined.
+func (cba *ControlBase) DPI() int {
+	if dpi := w32.GetDpiForWindow(cba.hwnd); dpi != 0 {
+		return int(dpi)
+	}
+
+	hdc := w32.GetDC(cba.hwnd)
+	defer w32.ReleaseDC(cba.hwnd, hdc)
+	if dpi := w32.GetDeviceCaps(hdc, w32.LOGPIXELSX); dpi != 0 {
+		return dpi
+	}
+	return baseDPI
+}
+
+// LogicalToPhysical scales a logical (96 DPI) pixel value to the control's
+// current monitor DPI.
+func (cba *ControlBase) LogicalToPhysical(v int) int {
+	return v * cba.DPI() / baseDPI
+}
+
+// PhysicalToLogical scales a physical pixel value, measured at the
+// control's current monitor DPI, back down to logical (96 DPI) pixels.
+func (cba *ControlBase) PhysicalToLogical(v int) int {
+	return v * baseDPI / cba.DPI()
+}
+
+// OnDPIChanged fires after the control's effective DPI changes, typically
+// because a top-level form was dragged to a monitor with a different
+// scale factor.
+func (cba *ControlBase) OnDPIChanged() *EventManager {
+	return &cba.onDPIChanged
+}
+
+// dpiAwareSize scales width/height to physical pixels when the control
+// opted into DPI-aware auto-scaling.
+func (cba *ControlBase) dpiAwareSize(width, height int) (int, int) {
+	if !cba.dpiAware {
+		return width, height
+	}
+	return cba.LogicalToPhysical(width), cba.LogicalToPhysical(height)
+}
+
+// dpiAwarePos scales x/y to physical pixels when the control opted into
+// DPI-aware auto-scaling.
+func (cba *ControlBase) dpiAwarePos(x, y int) (int, int) {
+	if !cba.dpiAware {
+		return x, y
+	}
+	return cba.LogicalToPhysical(x), cba.LogicalToPhysical(y)
+}
+
+// SetDPIAware turns on automatic logical-to-physical scaling of SetSize,
+// SetPos and SetFont for this control, and hooks WM_DPICHANGED /
+// WM_DPICHANGED_BEFOREPARENT so the control reacts when it crosses
+// monitors. Existing pixel-based code keeps working unchanged unless it
+// opts in.
+func (cba *ControlBase) SetDPIAware(b bool) {
+	cba.dpiAware = b
+	if b {
+		cba.ensureDPIHook()
+	}
+}
+
+// ensureDPIHook installs handleDPIMessage on cba.hwnd's extension chain
+// (see msgchain.go), once, so WM_DPICHANGED actually reaches
+// onDPIChangedMsg instead of going unhandled.
+func (cba *ControlBase) ensureDPIHook() {
+	if cba.dpiHooked {
+		return
+	}
+	cba.dpiHooked = true
+	cba.lastDPI = cba.DPI()
+	addChainedHandler(cba.hwnd, cba.handleDPIMessage)
+}
+
+func (cba *ControlBase) handleDPIMessage(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	switch msg {
+	case w32.WM_DPICHANGED:
+		newDPI := int(wparam & 0xffff)
+		suggested := (*w32.RECT)(unsafe.Pointer(lparam))
+		cba.onDPIChangedMsg(newDPI, suggested)
+		return 0, true
+	case w32.WM_DPICHANGED_BEFOREPARENT:
+		cba.onDPIChangedMsg(cba.DPI(), nil)
+		return 0, true
+	}
+	return 0, false
+}
+
+// onDPIChangedMsg handles WM_DPICHANGED / WM_DPICHANGED_BEFOREPARENT for a
+// top-level form: it re-creates the form's font at the new DPI (while
+// dpiAware is set), rescales every child's bounds by the same ratio, then
+// fires OnDPIChanged.
+func (cba *ControlBase) onDPIChangedMsg(newDPI int, suggested *w32.RECT) {
+	oldDPI := cba.lastDPI
+	cba.lastDPI = newDPI
+
+	if cba.font != nil {
+		// SetFont already re-creates the font via withDPI(cba.DPI()) when
+		// dpiAware is set; doing it again here first would create and
+		// immediately discard an extra HFONT every DPI change. After
+		// SetDPIAware(false) the hook stays installed and the font is
+		// re-sent unchanged.
+		cba.SetFont(cba.font)
+	}
+
+	if suggested != nil {
+		w32.SetWindowPos(cba.hwnd, 0,
+			int(suggested.Left), int(suggested.Top),
+			int(suggested.Right-suggested.Left), int(suggested.Bottom-suggested.Top),
+			w32.SWP_NOZORDER|w32.SWP_NOACTIVATE)
+	}
+
+	cba.rescaleChildrenForDPI(oldDPI, newDPI)
+	cba.onDPIChanged.Fire()
+}
+
+// rescaleChildrenForDPI recursively resizes and repositions every
+// descendant window by the newDPI/oldDPI ratio, so a top-level form
+// crossing monitors keeps its children in the same relative place and
+// size instead of keeping their old, now-wrong, pixel geometry.
+func (cba *ControlBase) rescaleChildrenForDPI(oldDPI, newDPI int) {
+	if oldDPI == 0 || newDPI == oldDPI {
+		return
+	}
+	for _, child := range w32.EnumChildWindows(cba.hwnd) {
+		rect := w32.GetWindowRect(child)
+		// MoveWindow positions child relative to its own parent, which
+		// for a nested descendant is not cba.hwnd.
+		x, y, _ := w32.ScreenToClient(w32.GetParent(child), int(rect.Left), int(rect.Top))
+		width := int(rect.Right-rect.Left) * newDPI / oldDPI
+		height := int(rect.Bottom-rect.Top) * newDPI / oldDPI
+		w32.MoveWindow(child, x*newDPI/oldDPI, y*newDPI/oldDPI, width, height, true)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ * Copyright (C) 2010-2013 Allen Dang. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"syscall"
+
+	"github.com/scroot/winc/w32"
+)
+
+// Font wraps a Windows GDI font (HFONT) together with the logical
+// (96 DPI) family/height/style it was created from, so it can be
+// re-created at a different DPI without losing the caller's original
+// request. See ControlBase.SetFont and withDPI.
+type Font struct {
+	hfont  w32.HFONT
+	family string
+	height int
+	bold   bool
+	italic bool
+}
+
+// NewFont creates a Font from a family name and a logical (96 DPI) pixel
+// height.
+func NewFont(family string, height int, bold, italic bool) *Font {
+	return newFontForDPI(family, height, bold, italic, baseDPI)
+}
+
+// newFontForDPI creates the HFONT for family/height/bold/italic scaled
+// from logical (96 DPI) height to dpi.
+func newFontForDPI(family string, height int, bold, italic bool, dpi int) *Font {
+	weight := w32.FW_NORMAL
+	if bold {
+		weight = w32.FW_BOLD
+	}
+
+	var lf w32.LOGFONT
+	lf.LfHeight = -int32(height * dpi / baseDPI)
+	lf.LfWeight = int32(weight)
+	if italic {
+		lf.LfItalic = 1
+	}
+	copy(lf.LfFaceName[:], syscall.StringToUTF16(family))
+
+	hfont := w32.CreateFontIndirect(&lf)
+	if hfont == 0 {
+		panic("CreateFontIndirect failed")
+	}
+
+	return &Font{hfont: hfont, family: family, height: height, bold: bold, italic: italic}
+}
+
+// Dispose releases the underlying HFONT.
+func (f *Font) Dispose() {
+	if f.hfont != 0 {
+		w32.DeleteObject(w32.HGDIOBJ(f.hfont))
+		f.hfont = 0
+	}
+}
+
+// withDPI returns a new Font carrying f's family/height/style but with
+// its HFONT re-created for dpi, so a control that switches monitors keeps
+// the same logical point size instead of the old, now mis-scaled, pixel
+// height. Called from ControlBase.SetFont, which onDPIChangedMsg goes
+// through.
+func (f *Font) withDPI(dpi int) *Font {
+	return newFontForDPI(f.family, f.height, f.bold, f.italic, dpi)
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/scroot/winc/w32"
+)
+
+// Icon wraps an HICON loaded from an .ico file or from a resource embedded
+// in the executable, for use with NotifyIcon and window icons.
+type Icon struct {
+	handle w32.HICON
+}
+
+// NewIconFromFile loads an icon from a .ico file on disk.
+func NewIconFromFile(filePath string) (*Icon, error) {
+	hIcon := w32.LoadImage(
+		0,
+		syscall.StringToUTF16Ptr(filePath),
+		w32.IMAGE_ICON,
+		0, 0,
+		w32.LR_LOADFROMFILE|w32.LR_DEFAULTSIZE,
+	)
+	if hIcon == 0 {
+		return nil, fmt.Errorf("LoadImage failed for %q", filePath)
+	}
+	return &Icon{handle: w32.HICON(hIcon)}, nil
+}
+
+// NewIconFromResource loads an icon embedded in the running executable by
+// resource ID, as set by a .rc/.syso file.
+func NewIconFromResource(resourceID uint16) (*Icon, error) {
+	hInstance := w32.GetModuleHandle("")
+	hIcon := w32.LoadIcon(hInstance, w32.MakeIntResource(resourceID))
+	if hIcon == 0 {
+		return nil, fmt.Errorf("LoadIcon failed for resource %d", resourceID)
+	}
+	return &Icon{handle: hIcon}, nil
+}
+
+// Handle returns the underlying Win32 icon handle.
+func (i *Icon) Handle() w32.HICON {
+	return i.handle
+}
+
+// Dispose destroys the icon and releases its GDI resources.
+func (i *Icon) Dispose() {
+	if i.handle != 0 {
+		w32.DestroyIcon(i.handle)
+		i.handle = 0
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"sync"
+
+	"github.com/scroot/winc/w32"
+)
+
+// invokeMsg is the private registered window message used to marshal a
+// func() call from an arbitrary goroutine onto the UI thread that owns a
+// ControlBase's hwnd. WPARAM carries the key into invokeRequests.
+var invokeMsg = w32.RegisterWindowMessage("winc.Invoke")
+
+var (
+	invokeMu      sync.Mutex
+	nextInvokeKey uintptr = 1
+)
+
+// invokeRequest bundles a queued callback with the channel Invoke waits on
+// (nil for the fire-and-forget BeginInvoke) and the hwnd it was queued
+// against, so a destroyed window's callbacks can be found and cancelled.
+type invokeRequest struct {
+	hwnd w32.HWND
+	fn   func()
+	done chan struct{}
+}
+
+var invokeRequests = make(map[uintptr]*invokeRequest)
+
+// Invoke runs fn on cba's UI thread and blocks until it has finished
+// running, even when called from another goroutine. It is safe to call
+// from the UI thread itself, in which case fn runs immediately. If the
+// window is already gone when fn is posted, or is closed before fn is
+// dispatched, Invoke returns without running fn.
+func (cba *ControlBase) Invoke(fn func()) {
+	if !cba.InvokeRequired() {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	cba.postInvoke(fn, done)
+	<-done
+}
+
+// BeginInvoke queues fn to run on cba's UI thread and returns immediately
+// without waiting for it to execute, also when called from the UI thread
+// itself. If cba has no window yet, fn runs immediately instead.
+func (cba *ControlBase) BeginInvoke(fn func()) {
+	if cba.hwnd == 0 {
+		fn()
+		return
+	}
+	cba.postInvoke(fn, nil)
+}
+
+func (cba *ControlBase) postInvoke(fn func(), done chan struct{}) {
+	cba.ensureInvokeHook()
+
+	invokeMu.Lock()
+	key := nextInvokeKey
+	nextInvokeKey++
+	invokeRequests[key] = &invokeRequest{hwnd: cba.hwnd, fn: fn, done: done}
+	invokeMu.Unlock()
+
+	if !w32.PostMessage(cba.hwnd, invokeMsg, uintptr(key), 0) {
+		// The window is gone (e.g. Close() raced us): drop the
+		// callback rather than leak it or block forever.
+		invokeMu.Lock()
+		delete(invokeRequests, key)
+		invokeMu.Unlock()
+		if done != nil {
+			close(done)
+		}
+	}
+}
+
+// ensureInvokeHook installs handleInvokeMsg on cba.hwnd's extension chain
+// (see msgchain.go), once, so invokeMsg actually reaches dispatchInvoke
+// instead of being dropped unhandled.
+func (cba *ControlBase) ensureInvokeHook() {
+	cba.hookMu.Lock()
+	defer cba.hookMu.Unlock()
+	if cba.invokeHooked {
+		return
+	}
+	cba.invokeHooked = true
+	addChainedHandler(cba.hwnd, handleInvokeMsg)
+}
+
+func handleInvokeMsg(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	if msg != invokeMsg {
+		return 0, false
+	}
+	dispatchInvoke(wparam)
+	return 0, true
+}
+
+// dispatchInvoke runs the callback queued under wParam, if any. It is
+// called by handleInvokeMsg when it sees invokeMsg.
+func dispatchInvoke(wParam uintptr) {
+	invokeMu.Lock()
+	req, ok := invokeRequests[wParam]
+	if ok {
+		delete(invokeRequests, wParam)
+	}
+	invokeMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	req.fn()
+	if req.done != nil {
+		close(req.done)
+	}
+}
+
+// cancelPendingInvokes unblocks every Invoke call still queued against
+// hwnd without running its callback, so Close() can never deadlock
+// waiting on a message that will never be dispatched once the window is
+// destroyed. It is called from ControlBase.Close().
+func cancelPendingInvokes(hwnd w32.HWND) {
+	invokeMu.Lock()
+	defer invokeMu.Unlock()
+
+	for key, req := range invokeRequests {
+		if req.hwnd != hwnd {
+			continue
+		}
+		if req.done != nil {
+			close(req.done)
+		}
+		delete(invokeRequests, key)
+	}
+}
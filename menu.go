@@ -6,6 +6,7 @@ package winc
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
 	"unsafe"
 
@@ -41,16 +42,43 @@ type MenuItem struct {
 	checkable bool
 	checked   bool
 
+	ownerDrawn bool
+	// textColor and backgroundColor default to the system menu colors (see
+	// addMenuItem) so SetTextColor, SetBackgroundColor and
+	// SetBoldWhenDefault each owner-draw the item correctly on their own,
+	// without requiring the other color setters to also be called.
+	textColor       w32.COLORREF
+	backgroundColor w32.COLORREF
+	boldWhenDefault bool
+	radioGroup      int // 0 means "not part of a radio group".
+
 	id uint16
 
+	// ownerHwnd is the hwnd of the form (or context-menu owner) this item
+	// was last attached to via Menu.Show, ControlBase.SetContextMenu, or
+	// NotifyIcon.SetContextMenu. It scopes accelerator dispatch (see
+	// accelerator.go's handleKeyDownMsg) to the form actually showing the
+	// item, instead of matching the same shortcut across every open form.
+	ownerHwnd w32.HWND
+
 	onClick EventManager
 }
 
+// measuredMenuItemSize caches the owner-drawn size of a menu item for a
+// given DPI, so WM_MEASUREITEM doesn't have to re-measure text/bitmaps on
+// every redraw.
+type measuredMenuItemSize struct {
+	cx, cy uint32
+}
+
+var menuItemSizeCache = make(map[uint16]map[int]measuredMenuItemSize)
+
 func NewContextMenu() *MenuItem {
 	hMenu := w32.CreatePopupMenu()
 	if hMenu == 0 {
 		panic("failed CreateMenu")
 	}
+	promoteMenuStyle(hMenu)
 
 	item := &MenuItem{
 		hMenu:    hMenu,
@@ -61,15 +89,58 @@ func NewContextMenu() *MenuItem {
 
 func (m *Menu) Dispose() {
 	if m.hMenu != 0 {
+		forgetMenuItems(m.hMenu)
 		w32.DestroyMenu(m.hMenu)
 		m.hMenu = 0
 	}
 }
 
+// forgetMenuItems recursively drops every MenuItem in hMenu's tree (and
+// any submenus it contains) from the package-global actionsByID,
+// shortcut2Action, menuItems and menuItemSizeCache maps. Without this, a
+// torn-down menu's items keep matching in handleKeyDownMsg and
+// findMenuItemByID forever, and since Windows recycles HMENU/HWND values
+// a later unrelated menu can inherit a stale entry.
+func forgetMenuItems(hMenu w32.HMENU) {
+	for _, mi := range menuItems[hMenu] {
+		if mi.hSubMenu != 0 {
+			forgetMenuItems(mi.hSubMenu)
+		}
+		delete(actionsByID, mi.id)
+		delete(shortcut2Action, mi.shortcut)
+		delete(menuItemSizeCache, mi.id)
+	}
+	delete(menuItems, hMenu)
+}
+
+// assignMenuOwner recursively tags every item in hMenu's tree (and any
+// submenus it contains) as owned by hwnd, so accelerator dispatch only
+// fires shortcuts for the form actually showing them. Called from
+// Menu.Show, ControlBase.SetContextMenu and NotifyIcon.SetContextMenu.
+func assignMenuOwner(hMenu w32.HMENU, hwnd w32.HWND) {
+	for _, mi := range menuItems[hMenu] {
+		mi.ownerHwnd = hwnd
+		if mi.hSubMenu != 0 {
+			assignMenuOwner(mi.hSubMenu, hwnd)
+		}
+	}
+}
+
 func (m *Menu) IsDisposed() bool {
 	return m.hMenu == 0
 }
 
+// displayText returns the label shown for mi: its text, with a
+// tab-separated shortcut hint appended when it has one. Used both for the
+// native MIIM_STRING item text and, so the hint doesn't disappear once an
+// item becomes owner-drawn, for measureMenuItem/drawMenuItem.
+func displayText(mi *MenuItem) string {
+	if s := mi.shortcut; s.Key != 0 {
+		return fmt.Sprintf("%s\t%s", mi.text, s.String())
+	}
+	return mi.text
+}
+
 func initMenuItemInfoFromAction(mii *w32.MENUITEMINFO, a *MenuItem) {
 	mii.CbSize = uint32(unsafe.Sizeof(*mii))
 	mii.FMask = w32.MIIM_FTYPE | w32.MIIM_ID | w32.MIIM_STATE | w32.MIIM_STRING
@@ -81,16 +152,18 @@ func initMenuItemInfoFromAction(mii *w32.MENUITEMINFO, a *MenuItem) {
 		mii.FType = w32.MFT_SEPARATOR
 	} else {
 		mii.FType = w32.MFT_STRING
-		var text string
-		if s := a.shortcut; s.Key != 0 {
-			text = fmt.Sprintf("%s\t%s", a.text, s.String())
+		if a.shortcut.Key != 0 {
 			shortcut2Action[a.shortcut] = a
-		} else {
-			text = a.text
 		}
-		mii.DwTypeData = syscall.StringToUTF16Ptr(text)
+		mii.DwTypeData = syscall.StringToUTF16Ptr(displayText(a))
 		mii.Cch = uint32(len([]rune(a.text)))
 	}
+	if a.ownerDrawn {
+		mii.FType |= w32.MFT_OWNERDRAW
+	}
+	if a.radioGroup != 0 {
+		mii.FType |= w32.MFT_RADIOCHECK
+	}
 	mii.WID = uint32(a.id)
 
 	if a.Enabled() {
@@ -114,20 +187,91 @@ func initMenuItemInfoFromAction(mii *w32.MENUITEMINFO, a *MenuItem) {
 
 // Show menu on the main window.
 func (m *Menu) Show() {
+	ensureMenuDrawHook(m.hwnd)
+	ensureMenuSelectHook(m.hwnd)
+	ensureAccelTable(m.hwnd)
+	assignMenuOwner(m.hMenu, m.hwnd)
 	if !w32.DrawMenuBar(m.hwnd) {
 		panic("DrawMenuBar failed")
 	}
 }
 
+var (
+	menuDrawHookedMu sync.Mutex
+	menuDrawHooked   = make(map[w32.HWND]bool)
+)
+
+// ensureMenuDrawHook installs handleMenuDrawMsg on hwnd's extension chain
+// (see msgchain.go), once, so WM_MEASUREITEM/WM_DRAWITEM for owner-drawn
+// items on this menu actually reach measureMenuItem/drawMenuItem.
+func ensureMenuDrawHook(hwnd w32.HWND) {
+	menuDrawHookedMu.Lock()
+	defer menuDrawHookedMu.Unlock()
+	if menuDrawHooked[hwnd] {
+		return
+	}
+	menuDrawHooked[hwnd] = true
+	addChainedHandler(hwnd, handleMenuDrawMsg)
+}
+
+// forgetMenuDrawHook drops hwnd's entry from menuDrawHooked, called
+// alongside removeChainedHandlers when hwnd is torn down. Without this, a
+// later window that reuses the same (OS-recycled) hwnd would find
+// menuDrawHooked[hwnd] already true and silently skip installing its own
+// hook even though the real subclass chain was already removed, so its
+// owner-drawn menu items would never receive WM_MEASUREITEM/WM_DRAWITEM.
+func forgetMenuDrawHook(hwnd w32.HWND) {
+	menuDrawHookedMu.Lock()
+	defer menuDrawHookedMu.Unlock()
+	delete(menuDrawHooked, hwnd)
+}
+
+// closeMenuDrawHook forgets cba.hwnd's owner-draw hook bookkeeping.
+// Called by ControlBase.Close().
+func (cba *ControlBase) closeMenuDrawHook() {
+	forgetMenuDrawHook(cba.hwnd)
+}
+
+func handleMenuDrawMsg(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	switch msg {
+	case w32.WM_MEASUREITEM:
+		mis := (*w32.MEASUREITEMSTRUCT)(unsafe.Pointer(lparam))
+		if measureMenuItem(hwnd, mis) {
+			return 1, true
+		}
+	case w32.WM_DRAWITEM:
+		dis := (*w32.DRAWITEMSTRUCT)(unsafe.Pointer(lparam))
+		if drawMenuItem(dis) {
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
 // AddSubMenu returns item that is used as submenu to perform AddItem(s).
 func (m *Menu) AddSubMenu(text string) *MenuItem {
 	hSubMenu := w32.CreateMenu()
 	if hSubMenu == 0 {
 		panic("failed CreateMenu")
 	}
+	promoteMenuStyle(hSubMenu)
 	return addMenuItem(m.hMenu, hSubMenu, text, Shortcut{}, nil, false)
 }
 
+// promoteMenuStyle sets MNS_CHECKORBMP on hMenu so owner-drawn bitmaps and
+// checkmarks share a single column instead of the checkmark being drawn
+// beside the bitmap.
+func promoteMenuStyle(hMenu w32.HMENU) {
+	info := w32.MENUINFO{
+		CbSize:  uint32(unsafe.Sizeof(w32.MENUINFO{})),
+		FMask:   w32.MIM_STYLE,
+		DwStyle: w32.MNS_CHECKORBMP,
+	}
+	if !w32.SetMenuInfo(hMenu, &info) {
+		panic("SetMenuInfo failed")
+	}
+}
+
 func (mi *MenuItem) OnClick() *EventManager {
 	return &mi.onClick
 }
@@ -154,14 +298,16 @@ func (mi *MenuItem) AddItemWithBitmap(text string, shortcut Shortcut, image *Bit
 // AddItem to the menu, set text to "-" for separators.
 func addMenuItem(hMenu, hSubMenu w32.HMENU, text string, shortcut Shortcut, image *Bitmap, checkable bool) *MenuItem {
 	item := &MenuItem{
-		hMenu:     hMenu,
-		hSubMenu:  hSubMenu,
-		text:      text,
-		shortcut:  shortcut,
-		image:     image,
-		enabled:   true,
-		id:        nextMenuItemID,
-		checkable: checkable,
+		hMenu:           hMenu,
+		hSubMenu:        hSubMenu,
+		text:            text,
+		shortcut:        shortcut,
+		image:           image,
+		enabled:         true,
+		id:              nextMenuItemID,
+		checkable:       checkable,
+		textColor:       w32.GetSysColor(w32.COLOR_MENUTEXT),
+		backgroundColor: w32.GetSysColor(w32.COLOR_MENU),
 		//visible:  true,
 	}
 	nextMenuItemID++
@@ -201,6 +347,12 @@ func (mi *MenuItem) update() {
 		panic("SetMenuItemInfo failed")
 	}
 	//mi.menu.MenuItemChange(mi)
+	markAllAccelTablesDirty()
+
+	// Any property update() applies can change the item's rendered size
+	// (text, image, or the owner-drawn shortcut hint), so the previous
+	// WM_MEASUREITEM result no longer applies.
+	delete(menuItemSizeCache, mi.id)
 }
 
 func (mi *MenuItem) IsSeparator() bool { return mi.text == "-" }
@@ -212,8 +364,42 @@ func (mi *MenuItem) SetEnabled(b bool) { mi.enabled = b; mi.update() }
 func (mi *MenuItem) Checkable() bool     { return mi.checkable }
 func (mi *MenuItem) SetCheckable(b bool) { mi.checkable = b; mi.update() }
 
-func (mi *MenuItem) Checked() bool     { return mi.checked }
-func (mi *MenuItem) SetChecked(b bool) { mi.checked = b; mi.update() }
+func (mi *MenuItem) Checked() bool { return mi.checked }
+func (mi *MenuItem) SetChecked(b bool) {
+	mi.checked = b
+	if b && mi.radioGroup != 0 {
+		first, last := radioGroupRange(mi)
+		w32.CheckMenuRadioItem(mi.hMenu, uint32(first), uint32(last), uint32(mi.id), w32.MF_BYCOMMAND)
+		// CheckMenuRadioItem only updates the OS-side menu state; mirror
+		// it into the Go-side checked field for every sibling so
+		// Checked() doesn't keep reporting the previously-selected item
+		// as checked.
+		for _, sibling := range menuItems[mi.hMenu] {
+			if sibling.radioGroup == mi.radioGroup {
+				sibling.checked = sibling.id == mi.id
+			}
+		}
+		return
+	}
+	mi.update()
+}
+
+// radioGroupRange returns the first and last menu item IDs sharing mi's
+// radio group, as required by CheckMenuRadioItem's idFirst/idLast range.
+func radioGroupRange(mi *MenuItem) (first, last uint16) {
+	for _, sibling := range menuItems[mi.hMenu] {
+		if sibling.radioGroup != mi.radioGroup {
+			continue
+		}
+		if first == 0 || sibling.id < first {
+			first = sibling.id
+		}
+		if sibling.id > last {
+			last = sibling.id
+		}
+	}
+	return
+}
 
 func (mi *MenuItem) Text() string     { return mi.text }
 func (mi *MenuItem) SetText(s string) { mi.text = s; mi.update() }
@@ -223,3 +409,122 @@ func (mi *MenuItem) SetImage(b *Bitmap) { mi.image = b; mi.update() }
 
 func (mi *MenuItem) ToolTip() string     { return mi.toolTip }
 func (mi *MenuItem) SetToolTip(s string) { mi.toolTip = s; mi.update() }
+
+// SetTextColor owner-draws the item so its label renders in c instead of
+// the system menu text color.
+func (mi *MenuItem) SetTextColor(c w32.COLORREF) {
+	mi.textColor = c
+	mi.ownerDrawn = true
+	mi.update()
+}
+
+// SetBackgroundColor owner-draws the item so its row renders in c instead
+// of the system menu background color.
+func (mi *MenuItem) SetBackgroundColor(c w32.COLORREF) {
+	mi.backgroundColor = c
+	mi.ownerDrawn = true
+	mi.update()
+}
+
+// SetBoldWhenDefault owner-draws the item in bold whenever it is the
+// menu's default item (ODS_DEFAULT).
+func (mi *MenuItem) SetBoldWhenDefault(b bool) {
+	mi.boldWhenDefault = b
+	mi.ownerDrawn = true
+	mi.update()
+}
+
+// RadioGroup puts this item in mutually-exclusive radio group id: checking
+// it via CheckMenuRadioItem unchecks every other item sharing the same id
+// in the menu, and the item renders with a bullet instead of a checkmark.
+func (mi *MenuItem) RadioGroup(id int) {
+	mi.radioGroup = id
+	mi.checkable = true
+	mi.update()
+}
+
+func (mi *MenuItem) measuredSize(dpi int) (measuredMenuItemSize, bool) {
+	byDPI, ok := menuItemSizeCache[mi.id]
+	if !ok {
+		return measuredMenuItemSize{}, false
+	}
+	size, ok := byDPI[dpi]
+	return size, ok
+}
+
+func (mi *MenuItem) cacheMeasuredSize(dpi int, size measuredMenuItemSize) {
+	if menuItemSizeCache[mi.id] == nil {
+		menuItemSizeCache[mi.id] = make(map[int]measuredMenuItemSize)
+	}
+	menuItemSizeCache[mi.id][dpi] = size
+}
+
+// measureMenuItem handles WM_MEASUREITEM for owner-drawn menu items,
+// called by handleMenuDrawMsg with the MEASUREITEMSTRUCT pointed to by
+// lParam.
+func measureMenuItem(hwnd w32.HWND, mis *w32.MEASUREITEMSTRUCT) bool {
+	if mis.CtlType != w32.ODT_MENU {
+		return false
+	}
+	mi := findMenuItemByID(int(mis.ItemID))
+	if mi == nil || !mi.ownerDrawn {
+		return false
+	}
+
+	dpi := int(w32.GetDpiForWindow(hwnd))
+	if size, ok := mi.measuredSize(dpi); ok {
+		mis.ItemWidth = size.cx
+		mis.ItemHeight = size.cy
+		return true
+	}
+
+	hdc := w32.GetDC(hwnd)
+	defer w32.ReleaseDC(hwnd, hdc)
+	extent := w32.GetTextExtentPoint32(hdc, displayText(mi))
+
+	size := measuredMenuItemSize{cx: uint32(extent.CX) + 16, cy: uint32(extent.CY) + 8}
+	mi.cacheMeasuredSize(dpi, size)
+	mis.ItemWidth = size.cx
+	mis.ItemHeight = size.cy
+	return true
+}
+
+// drawMenuItem handles WM_DRAWITEM for owner-drawn menu items, applying
+// the item's custom text/background color and bold-when-default styling.
+// It renders displayText(mi), the same text plus shortcut hint a
+// non-owner-drawn item gets from Windows for free, so becoming
+// owner-drawn doesn't silently drop the shortcut from the rendered row.
+// It is called by handleMenuDrawMsg with the DRAWITEMSTRUCT pointed to by
+// lParam.
+func drawMenuItem(dis *w32.DRAWITEMSTRUCT) bool {
+	if dis.CtlType != w32.ODT_MENU {
+		return false
+	}
+	mi := findMenuItemByID(int(dis.ItemID))
+	if mi == nil || !mi.ownerDrawn {
+		return false
+	}
+
+	bg := mi.backgroundColor
+	if dis.ItemState&w32.ODS_SELECTED != 0 {
+		bg = w32.GetSysColor(w32.COLOR_HIGHLIGHT)
+	}
+	brush := w32.CreateSolidBrush(bg)
+	w32.FillRect(dis.HDC, &dis.RcItem, brush)
+	w32.DeleteObject(w32.HGDIOBJ(brush))
+
+	w32.SetBkMode(dis.HDC, w32.TRANSPARENT)
+	w32.SetTextColor(dis.HDC, mi.textColor)
+
+	text := displayText(mi)
+	if mi.boldWhenDefault && dis.ItemState&w32.ODS_DEFAULT != 0 {
+		font := w32.CreateFontIndirect(&w32.LOGFONT{LfWeight: w32.FW_BOLD})
+		old := w32.SelectObject(dis.HDC, w32.HGDIOBJ(font))
+		w32.DrawText(dis.HDC, text, &dis.RcItem, w32.DT_SINGLELINE|w32.DT_VCENTER)
+		w32.SelectObject(dis.HDC, old)
+		w32.DeleteObject(w32.HGDIOBJ(font))
+	} else {
+		w32.DrawText(dis.HDC, text, &dis.RcItem, w32.DT_SINGLELINE|w32.DT_VCENTER)
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"sync"
+
+	"github.com/scroot/winc/w32"
+)
+
+// msgHandlerFunc is one link in a chain of extension hooks installed
+// against a single hwnd. It reports whether it handled msg; an unhandled
+// message falls through to the next link, and finally to DefWindowProc.
+type msgHandlerFunc func(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (result uintptr, handled bool)
+
+var (
+	chainedMu       sync.Mutex
+	chainedHandlers = make(map[w32.HWND][]msgHandlerFunc)
+)
+
+// chainSubclassID is the uIdSubclass every feature shares: there is only
+// ever one dispatchChainedMsgProc subclass per hwnd, fanning out to
+// however many handlers have been added to it.
+const chainSubclassID = 1
+
+// addChainedHandler appends fn to hwnd's chain of extension hooks,
+// installing the chain itself via SetWindowSubclass the first time any
+// feature (Invoke, accelerators, DPI, tooltips, owner-drawn menus) touches
+// that hwnd. SetWindowSubclass layers dispatchChainedMsgProc on top of
+// whatever hwnd's WNDPROC already is (the class default, or whatever a
+// control registered for itself via RegMsgHandler at construction) rather
+// than replacing it, so an unhandled message still reaches that original
+// dispatch via DefSubclassProc instead of being swallowed by
+// DefWindowProc.
+func addChainedHandler(hwnd w32.HWND, fn msgHandlerFunc) {
+	chainedMu.Lock()
+	defer chainedMu.Unlock()
+
+	_, exists := chainedHandlers[hwnd]
+	chainedHandlers[hwnd] = append(chainedHandlers[hwnd], fn)
+	if !exists {
+		w32.SetWindowSubclass(hwnd, dispatchChainedMsgProc, chainSubclassID, 0)
+	}
+}
+
+// removeChainedHandlers drops every extension hook registered against
+// hwnd and removes the subclass installed for them. Called from
+// ControlBase.Close() so a destroyed window's chain can't be invoked
+// again and doesn't leak.
+func removeChainedHandlers(hwnd w32.HWND) {
+	chainedMu.Lock()
+	defer chainedMu.Unlock()
+	if _, exists := chainedHandlers[hwnd]; !exists {
+		return
+	}
+	delete(chainedHandlers, hwnd)
+	w32.RemoveWindowSubclass(hwnd, dispatchChainedMsgProc, chainSubclassID)
+}
+
+func dispatchChainedMsgProc(hwnd w32.HWND, msg uint32, wparam, lparam uintptr, uIdSubclass, dwRefData uintptr) uintptr {
+	chainedMu.Lock()
+	handlers := append([]msgHandlerFunc(nil), chainedHandlers[hwnd]...)
+	chainedMu.Unlock()
+
+	for _, fn := range handlers {
+		if result, handled := fn(hwnd, msg, wparam, lparam); handled {
+			return result
+		}
+	}
+	return w32.DefSubclassProc(hwnd, msg, wparam, lparam)
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/scroot/winc/w32"
+)
+
+const notifyIconMessageClass = "wincNotifyIconOwner"
+
+var nextNotifyIconID uint32 = 1
+
+// NotifyIcon places an icon in the Windows notification area (system tray).
+//
+// It is backed by a hidden message-only owner window so that it can
+// receive the private callback message Shell_NotifyIconW posts for
+// clicks and balloon notifications, independent of any visible form.
+type NotifyIcon struct {
+	hwnd w32.HWND
+	id   uint32
+	icon w32.HICON
+
+	contextMenu *MenuItem
+
+	onClick          EventManager
+	onRClick         EventManager
+	onBalloonClick   EventManager
+	onBalloonTimeout EventManager
+}
+
+// NewNotifyIcon creates and immediately shows a notification area icon.
+func NewNotifyIcon() *NotifyIcon {
+	RegClassOnlyOnce(notifyIconMessageClass)
+
+	hwnd := w32.CreateWindowEx(0, notifyIconMessageClass, "", 0, 0, 0, 0, 0, w32.HWND_MESSAGE, 0, 0, nil)
+	if hwnd == 0 {
+		panic("cannot create notify icon owner window")
+	}
+
+	ni := &NotifyIcon{
+		hwnd: hwnd,
+		id:   nextNotifyIconID,
+	}
+	nextNotifyIconID++
+
+	RegMsgHandler(hwnd, ni.wndProc)
+
+	var nid w32.NOTIFYICONDATA
+	ni.initData(&nid)
+	nid.UFlags = w32.NIF_MESSAGE
+	if !w32.Shell_NotifyIcon(w32.NIM_ADD, &nid) {
+		panic("Shell_NotifyIcon NIM_ADD failed")
+	}
+	return ni
+}
+
+func (ni *NotifyIcon) initData(nid *w32.NOTIFYICONDATA) {
+	nid.CbSize = uint32(unsafe.Sizeof(*nid))
+	nid.Hwnd = ni.hwnd
+	nid.UID = ni.id
+	nid.UCallbackMessage = w32.WM_USER + 1
+	nid.HIcon = ni.icon
+}
+
+// SetIcon assigns icon (see NewIconFromFile and NewIconFromResource) to the
+// tray entry.
+func (ni *NotifyIcon) SetIcon(icon *Icon) {
+	ni.icon = icon.handle
+
+	var nid w32.NOTIFYICONDATA
+	ni.initData(&nid)
+	nid.UFlags = w32.NIF_ICON
+	if !w32.Shell_NotifyIcon(w32.NIM_MODIFY, &nid) {
+		panic("Shell_NotifyIcon NIM_MODIFY failed")
+	}
+}
+
+// SetToolTip sets the text shown when the mouse hovers over the icon.
+func (ni *NotifyIcon) SetToolTip(text string) {
+	var nid w32.NOTIFYICONDATA
+	ni.initData(&nid)
+	nid.UFlags = w32.NIF_TIP
+	copy(nid.SzTip[:], syscall.StringToUTF16(text))
+	if !w32.Shell_NotifyIcon(w32.NIM_MODIFY, &nid) {
+		panic("Shell_NotifyIcon NIM_MODIFY failed")
+	}
+}
+
+// ShowBalloon pops up a balloon notification above the tray icon.
+func (ni *NotifyIcon) ShowBalloon(title, text string, icon BalloonIcon) {
+	var nid w32.NOTIFYICONDATA
+	ni.initData(&nid)
+	nid.UFlags = w32.NIF_INFO
+	copy(nid.SzInfoTitle[:], syscall.StringToUTF16(title))
+	copy(nid.SzInfo[:], syscall.StringToUTF16(text))
+	nid.DwInfoFlags = uint32(icon)
+	if !w32.Shell_NotifyIcon(w32.NIM_MODIFY, &nid) {
+		panic("Shell_NotifyIcon NIM_MODIFY failed")
+	}
+}
+
+// BalloonIcon selects the stock icon shown beside a balloon notification.
+type BalloonIcon uint32
+
+const (
+	BalloonIconNone  BalloonIcon = w32.NIIF_NONE
+	BalloonIconInfo  BalloonIcon = w32.NIIF_INFO
+	BalloonIconWarn  BalloonIcon = w32.NIIF_WARNING
+	BalloonIconError BalloonIcon = w32.NIIF_ERROR
+)
+
+// ContextMenu returns the popup menu shown on right-click, if any.
+func (ni *NotifyIcon) ContextMenu() *MenuItem {
+	return ni.contextMenu
+}
+
+// SetContextMenu binds a popup MenuItem shown via TrackPopupMenu on right-click.
+func (ni *NotifyIcon) SetContextMenu(menu *MenuItem) {
+	ni.contextMenu = menu
+	menu.ownerHwnd = ni.hwnd
+	assignMenuOwner(menu.hSubMenu, ni.hwnd)
+}
+
+// Dispose removes the icon from the notification area and destroys the
+// hidden owner window.
+func (ni *NotifyIcon) Dispose() {
+	var nid w32.NOTIFYICONDATA
+	ni.initData(&nid)
+	w32.Shell_NotifyIcon(w32.NIM_DELETE, &nid)
+
+	removeChainedHandlers(ni.hwnd)
+	forgetMenuDrawHook(ni.hwnd)
+	forgetMenuSelectHook(ni.hwnd)
+	UnRegMsgHandler(ni.hwnd)
+	w32.DestroyWindow(ni.hwnd)
+	ni.hwnd = 0
+}
+
+func (ni *NotifyIcon) OnClick() *EventManager          { return &ni.onClick }
+func (ni *NotifyIcon) OnRClick() *EventManager         { return &ni.onRClick }
+func (ni *NotifyIcon) OnBalloonClick() *EventManager   { return &ni.onBalloonClick }
+func (ni *NotifyIcon) OnBalloonTimeout() *EventManager { return &ni.onBalloonTimeout }
+
+func (ni *NotifyIcon) wndProc(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) uintptr {
+	if msg == w32.WM_USER+1 {
+		switch lparam {
+		case w32.WM_LBUTTONUP:
+			ni.onClick.Fire()
+		case w32.WM_RBUTTONUP:
+			if ni.contextMenu != nil {
+				ensureMenuDrawHook(ni.hwnd)
+				ensureMenuSelectHook(ni.hwnd)
+				x, y := w32.GetCursorPos()
+				w32.SetForegroundWindow(ni.hwnd)
+				w32.TrackPopupMenu(ni.contextMenu.hSubMenu, w32.TPM_RIGHTBUTTON, x, y, 0, ni.hwnd, nil)
+				// Documented tray-icon workaround: without this follow-up
+				// message, the popup can fail to dismiss, or reappear, when
+				// the user clicks elsewhere.
+				w32.PostMessage(ni.hwnd, w32.WM_NULL, 0, 0)
+			}
+			ni.onRClick.Fire()
+		case w32.NIN_BALLOONUSERCLICK:
+			ni.onBalloonClick.Fire()
+		case w32.NIN_BALLOONTIMEOUT:
+			ni.onBalloonTimeout.Fire()
+		}
+		return 0
+	}
+	return w32.DefWindowProc(hwnd, msg, wparam, lparam)
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2019 The Winc Authors. All Rights Reserved.
+ */
+
+package winc
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/scroot/winc/w32"
+)
+
+// Tooltip wraps a TOOLTIPS_CLASS common control. A form lazily creates one
+// shared Tooltip the first time any of its controls calls SetToolTip, and
+// every subsequently registered control is added as a tool to that same
+// window.
+type Tooltip struct {
+	hwnd w32.HWND
+}
+
+// NewTooltip creates a tooltip window owned by parent, ready to have
+// controls registered with AddTool.
+func NewTooltip(parent w32.HWND) *Tooltip {
+	hwnd := w32.CreateWindowEx(
+		0,
+		syscall.StringToUTF16Ptr(w32.TOOLTIPS_CLASS),
+		nil,
+		w32.TTS_ALWAYSTIP,
+		w32.CW_USEDEFAULT, w32.CW_USEDEFAULT, w32.CW_USEDEFAULT, w32.CW_USEDEFAULT,
+		parent, 0, 0, nil,
+	)
+	if hwnd == 0 {
+		panic("cannot create tooltip window")
+	}
+	w32.SetWindowPos(hwnd, w32.HWND_TOPMOST, 0, 0, 0, 0, w32.SWP_NOMOVE|w32.SWP_NOSIZE|w32.SWP_NOACTIVATE)
+	return &Tooltip{hwnd: hwnd}
+}
+
+// AddTool registers hwnd with the tooltip, showing text on hover. Passing
+// TTF_SUBCLASS means the tooltip subclasses hwnd itself to detect
+// mouse-hover, so no WM_MOUSEMOVE relaying is needed from the caller.
+func (t *Tooltip) AddTool(hwnd w32.HWND, text string) {
+	ti := w32.TOOLINFO{
+		CbSize:   uint32(w32.SizeofTOOLINFO),
+		UFlags:   w32.TTF_SUBCLASS | w32.TTF_IDISHWND,
+		Hwnd:     hwnd,
+		UID:      uintptr(hwnd),
+		LpszText: syscall.StringToUTF16Ptr(text),
+	}
+	w32.SendMessage(t.hwnd, w32.TTM_ADDTOOL, 0, uintptr(unsafe.Pointer(&ti)))
+}
+
+// UpdateText changes the text shown for a previously added tool.
+func (t *Tooltip) UpdateText(hwnd w32.HWND, text string) {
+	ti := w32.TOOLINFO{
+		CbSize:   uint32(w32.SizeofTOOLINFO),
+		UFlags:   w32.TTF_SUBCLASS | w32.TTF_IDISHWND,
+		Hwnd:     hwnd,
+		UID:      uintptr(hwnd),
+		LpszText: syscall.StringToUTF16Ptr(text),
+	}
+	w32.SendMessage(t.hwnd, w32.TTM_UPDATETIPTEXT, 0, uintptr(unsafe.Pointer(&ti)))
+}
+
+// SetBalloon switches the tooltip to balloon style and sets its title and
+// stock icon, shown via TTM_SETTITLE.
+func (t *Tooltip) SetBalloon(title string, icon BalloonIcon) {
+	w32.SetWindowLong(t.hwnd, w32.GWL_STYLE, uint32(w32.GetWindowLong(t.hwnd, w32.GWL_STYLE))|w32.TTS_BALLOON)
+	w32.SendMessage(t.hwnd, w32.TTM_SETTITLE, uintptr(icon), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(title))))
+}
+
+// ShowNear pops up the tooltip text near (x, y) without requiring a hovering
+// mouse, used to surface MenuItem.ToolTip on WM_MENUSELECT.
+func (t *Tooltip) ShowNear(hwnd w32.HWND, text string, x, y int) {
+	t.AddTool(hwnd, text)
+	w32.SendMessage(t.hwnd, w32.TTM_TRACKPOSITION, 0, makeLong(x, y))
+
+	ti := w32.TOOLINFO{CbSize: uint32(w32.SizeofTOOLINFO), Hwnd: hwnd, UID: uintptr(hwnd)}
+	w32.SendMessage(t.hwnd, w32.TTM_TRACKACTIVATE, 1, uintptr(unsafe.Pointer(&ti)))
+}
+
+// makeLong packs x/y into a single uintptr the way Win32 MAKELONG packs a
+// POINT into LPARAM.
+func makeLong(x, y int) uintptr {
+	return uintptr(uint32(uint16(x)) | uint32(uint16(y))<<16)
+}
+
+// Hide deactivates any tracked tooltip shown via ShowNear.
+func (t *Tooltip) Hide(hwnd w32.HWND) {
+	ti := w32.TOOLINFO{CbSize: uint32(w32.SizeofTOOLINFO), Hwnd: hwnd, UID: uintptr(hwnd)}
+	w32.SendMessage(t.hwnd, w32.TTM_TRACKACTIVATE, 0, uintptr(unsafe.Pointer(&ti)))
+}
+
+// SetToolTip lazily creates this control's form-shared Tooltip window and
+// registers cba with it, so hovering cba shows text automatically.
+func (cba *ControlBase) SetToolTip(text string) {
+	tt := cba.formTooltip()
+	if cba.tooltip == nil {
+		tt.AddTool(cba.hwnd, text)
+	} else {
+		tt.UpdateText(cba.hwnd, text)
+	}
+	cba.tooltip = tt
+}
+
+// formTooltip returns the shared Tooltip for cba's top-level form,
+// creating it on first use. It walks all the way up to the actual
+// top-level window via GetAncestor rather than just cba.parent, so a
+// control nested two or more levels deep (e.g. inside a panel) still
+// shares the one tooltip window its form owns instead of getting its own
+// keyed off an intermediate parent's hwnd.
+func (cba *ControlBase) formTooltip() *Tooltip {
+	owner := cba.hwnd
+	if !cba.isForm {
+		owner = w32.GetAncestor(cba.hwnd, w32.GA_ROOT)
+	}
+
+	if tt, ok := formTooltips[owner]; ok {
+		return tt
+	}
+	tt := NewTooltip(owner)
+	formTooltips[owner] = tt
+	ensureMenuSelectHook(owner)
+	return tt
+}
+
+var formTooltips = make(map[w32.HWND]*Tooltip)
+
+// closeTooltip forgets cba's shared Tooltip. Called by ControlBase.Close()
+// for every control, but only actually runs for the form itself: formTooltips
+// is keyed by the top-level ancestor's hwnd (see formTooltip()), shared by
+// every child control on that form, so tearing down one child control must
+// not drop it out from under its still-live siblings.
+func (cba *ControlBase) closeTooltip() {
+	if !cba.isForm {
+		return
+	}
+	delete(formTooltips, cba.hwnd)
+}
+
+var (
+	menuSelectHookedMu sync.Mutex
+	menuSelectHooked   = make(map[w32.HWND]bool)
+)
+
+// ensureMenuSelectHook installs handleMenuSelectMsg on formHwnd's
+// extension chain (see msgchain.go), once, so WM_MENUSELECT actually
+// reaches showMenuSelectTooltip instead of MenuItem.ToolTip never being
+// displayed. Called both from Menu.Show() and from formTooltip(), since
+// either a menu or a plain control's SetToolTip call may be the first
+// thing to touch a given form.
+func ensureMenuSelectHook(formHwnd w32.HWND) {
+	menuSelectHookedMu.Lock()
+	defer menuSelectHookedMu.Unlock()
+	if menuSelectHooked[formHwnd] {
+		return
+	}
+	menuSelectHooked[formHwnd] = true
+	addChainedHandler(formHwnd, handleMenuSelectMsg)
+}
+
+// forgetMenuSelectHook drops formHwnd's entry from menuSelectHooked,
+// called alongside removeChainedHandlers when formHwnd is torn down.
+// Without this, a later window that reuses the same (OS-recycled) hwnd
+// would find menuSelectHooked[formHwnd] already true and silently skip
+// installing its own hook even though the real subclass chain was
+// already removed, so MenuItem.ToolTip would never show for it.
+func forgetMenuSelectHook(formHwnd w32.HWND) {
+	menuSelectHookedMu.Lock()
+	defer menuSelectHookedMu.Unlock()
+	delete(menuSelectHooked, formHwnd)
+}
+
+// closeMenuSelectHook forgets cba.hwnd's menu-select hook bookkeeping.
+// Called by ControlBase.Close().
+func (cba *ControlBase) closeMenuSelectHook() {
+	forgetMenuSelectHook(cba.hwnd)
+}
+
+func handleMenuSelectMsg(hwnd w32.HWND, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	if msg != w32.WM_MENUSELECT {
+		return 0, false
+	}
+	mi := findMenuItemByID(int(wparam & 0xffff))
+	showMenuSelectTooltip(hwnd, mi)
+	return 0, true
+}
+
+// showMenuSelectTooltip shows mi.ToolTip near the cursor for the
+// highlighted menu item, or hides the form's tooltip if mi has none. It is
+// called by handleMenuSelectMsg on WM_MENUSELECT, keyed off the owning
+// form's hwnd.
+func showMenuSelectTooltip(formHwnd w32.HWND, mi *MenuItem) {
+	tt, ok := formTooltips[formHwnd]
+	if !ok {
+		tt = NewTooltip(formHwnd)
+		formTooltips[formHwnd] = tt
+	}
+
+	if mi == nil || mi.toolTip == "" {
+		tt.Hide(formHwnd)
+		return
+	}
+
+	x, y := w32.GetCursorPos()
+	tt.ShowNear(formHwnd, mi.toolTip, x, y+20)
+}